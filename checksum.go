@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Checksums holds integrity digests a server advertised for a resource,
+// opportunistically picked up from its response headers.
+type Checksums struct {
+	MD5       string // hex, from Content-MD5 or a plain-MD5 ETag
+	CRC64ECMA string // decimal, as sent via x-oss-hash-crc64ecma
+}
+
+// etagMD5Pattern matches a strong ETag that is really just a hex MD5, the
+// convention S3/OSS-alikes use for non-multipart uploads.
+var etagMD5Pattern = regexp.MustCompile(`^[a-fA-F0-9]{32}$`)
+
+// checksumsFromHeader extracts whatever integrity digests it recognizes
+// from an HTTP response's headers.
+func checksumsFromHeader(h http.Header) Checksums {
+	var c Checksums
+
+	if v := h.Get("Content-MD5"); v != "" {
+		if raw, err := base64.StdEncoding.DecodeString(v); err == nil && len(raw) == md5.Size {
+			c.MD5 = hex.EncodeToString(raw)
+		}
+	}
+
+	if c.MD5 == "" {
+		if etag := strings.Trim(h.Get("ETag"), `"`); etagMD5Pattern.MatchString(etag) {
+			c.MD5 = strings.ToLower(etag)
+		}
+	}
+
+	c.CRC64ECMA = h.Get("x-oss-hash-crc64ecma")
+
+	return c
+}
+
+// verifyFile streams path through algo and compares the result against
+// want, returning the computed digest either way so callers can log it.
+func verifyFile(path, algo, want string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var got string
+	switch algo {
+	case "sha256":
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		got = hex.EncodeToString(h.Sum(nil))
+	case "md5":
+		h := md5.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		got = hex.EncodeToString(h.Sum(nil))
+	case "crc64ecma":
+		h := crc64.New(crc64.MakeTable(crc64.ECMA))
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		got = strconv.FormatUint(h.Sum64(), 10)
+	default:
+		return "", fmt.Errorf("unsupported checksum algorithm %q", algo)
+	}
+
+	if !strings.EqualFold(got, want) {
+		return got, fmt.Errorf("%s mismatch: want %s, got %s", algo, want, got)
+	}
+	return got, nil
+}
+
+// checksumFromFile fetches a checksum file (the kind `sha256sum`/`md5sum`
+// produce: "<hex>  <filename>" per line, optionally "*filename") and
+// returns the digest listed for name. A file holding nothing but a bare
+// hex digest (no filename column) is also accepted.
+func checksumFromFile(url, name string) (string, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("fetch checksum file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read checksum file: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) == 1 {
+		if fields := strings.Fields(lines[0]); len(fields) == 1 {
+			return fields[0], nil
+		}
+	}
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		digest := fields[0]
+		file := strings.TrimPrefix(fields[len(fields)-1], "*")
+		if file == name {
+			return digest, nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %q in %s", name, url)
+}
+
+// guessAlgoFromHex infers sha256 vs md5 from a hex digest's length, for
+// digests read out of a -checksum-file whose algorithm isn't stated.
+func guessAlgoFromHex(digest string) string {
+	switch len(digest) {
+	case 64:
+		return "sha256"
+	case 32:
+		return "md5"
+	default:
+		return ""
+	}
+}
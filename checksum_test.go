@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestChecksumsFromHeader(t *testing.T) {
+	cases := []struct {
+		name    string
+		header  http.Header
+		wantMD5 string
+		wantCRC string
+	}{
+		{
+			name:    "Content-MD5 base64",
+			header:  http.Header{"Content-Md5": []string{"XUFAKrxLKna5cZ2REBfFkg=="}},
+			wantMD5: "5d41402abc4b2a76b9719d911017c592",
+		},
+		{
+			name:    "ETag that is really an MD5",
+			header:  http.Header{"Etag": []string{`"5d41402abc4b2a76b9719d911017c592"`}},
+			wantMD5: "5d41402abc4b2a76b9719d911017c592",
+		},
+		{
+			name:   "strong ETag that isn't an MD5 is ignored",
+			header: http.Header{"Etag": []string{`"not-an-md5"`}},
+		},
+		{
+			name:    "crc64ecma header",
+			header:  http.Header{"X-Oss-Hash-Crc64ecma": []string{"12345"}},
+			wantCRC: "12345",
+		},
+		{
+			name:   "no recognized headers",
+			header: http.Header{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := checksumsFromHeader(c.header)
+			if got.MD5 != c.wantMD5 {
+				t.Errorf("MD5 = %q, want %q", got.MD5, c.wantMD5)
+			}
+			if got.CRC64ECMA != c.wantCRC {
+				t.Errorf("CRC64ECMA = %q, want %q", got.CRC64ECMA, c.wantCRC)
+			}
+		})
+	}
+}
+
+func TestGuessAlgoFromHex(t *testing.T) {
+	cases := []struct {
+		digest string
+		want   string
+	}{
+		{"5d41402abc4b2a76b9719d911017c592", "md5"},
+		{"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", "sha256"},
+		{"not-hex", ""},
+	}
+
+	for _, c := range cases {
+		if got := guessAlgoFromHex(c.digest); got != c.want {
+			t.Errorf("guessAlgoFromHex(%q) = %q, want %q", c.digest, got, c.want)
+		}
+	}
+}
@@ -3,13 +3,12 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,19 +17,16 @@ const (
 	CacheSize   = 1024
 	MaxRetries  = 3
 	HTTPTimeout = 20
-)
-
-var (
-	sizeNotMatch = errors.New("size not match")
 
-	httpClient = &http.Client{
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost: 30,
-		},
-		Timeout: HTTPTimeout * time.Second,
-	}
+	// journalSaveInterval throttles how often WriteAt persists the resume
+	// journal: WriteAt fires roughly once per CacheSize bytes per block,
+	// which with MaxThread concurrent blocks would otherwise serialize
+	// thousands of JSON-marshal + rename cycles per second behind f.mu.
+	journalSaveInterval = 500 * time.Millisecond
 )
 
+var sizeNotMatch = errors.New("size not match")
+
 type Status struct {
 	sync.RWMutex
 	Downloaded int64
@@ -49,13 +45,20 @@ func (s *Status) WithLock(fn func(), write bool) {
 }
 
 type Block struct {
-	Begin int64
-	End   int64
+	Begin      int64
+	End        int64
+	Downloaded int64 // 本块已写入的字节数，用于断点续传
 }
 
 type FileDownloader struct {
-	Url  string   // 下载地址
-	Size int64    // 文件大小
+	Urls         []string // 下载地址，支持多个互为镜像的源
+	Size         int64    // 文件大小
+	Fingerprint  string   // 用于断点续传时校验远端文件是否发生变化 (ETag 或 Last-Modified)
+	AcceptRanges bool     // 所有镜像是否都支持分块下载
+
+	Threads   int   // 并行分块数，0 表示按文件大小自动选择
+	ChunkSize int64 // 目标单块大小，非 0 时优先于 Threads 决定分块数
+
 	File *os.File // 要写入的文件
 
 	BlockList []Block // 用于记录未下载的文件块起始位置
@@ -66,32 +69,113 @@ type FileDownloader struct {
 	onError  func(error)
 
 	stopChan chan struct{}
+
+	journalPath     string
+	mu              sync.Mutex // 保护 BlockList 的并发写入，以及对 journal 的读-改-写
+	failedBlocks    int32      // 超过重试次数后放弃的块数，用于判断本次下载是否完整成功
+	journalMu       sync.Mutex
+	lastJournalSave time.Time // 上次持久化 journal 的时间，配合 journalSaveInterval 节流
+
+	mirrors []*mirror // 每个 Url 对应的协议实现与健康状况
+
+	ctx    context.Context // Start 传入的总下载上下文，Cancel 取消它
+	cancel context.CancelFunc
+
+	pauseMu   sync.Mutex
+	pauseCond *sync.Cond
+	paused    bool
+	genCtx    context.Context // 当前这段"非暂停"时期的上下文，Pause 时取消、Resume 时重建
+	genCancel context.CancelFunc
 }
 
-// 创建新的文件下载
+// 创建新的文件下载，urls 中的每一项都应指向同一份文件的内容
 // 如果 size <= 0 则自动获取文件大小
-func NewFileDownloader(url string, file *os.File, size int64) (*FileDownloader, error) {
-	if size <= 0 {
-		// 获取文件信息
-		resp, err := http.Head(url)
+// 当 resume 为 true 且 outfile 旁存在指纹匹配的 <outfile>.mget.json 时，
+// 会从中恢复 BlockList，跳过已下载完成的区间
+func NewFileDownloader(urls []string, file *os.File, size int64, outfile string, resume bool) (*FileDownloader, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no source URL given")
+	}
+
+	var (
+		fingerprint  string
+		acceptRanges = true
+	)
+
+	mirrors := make([]*mirror, len(urls))
+	for i, u := range urls {
+		fetcher, err := newFetcher(u)
 		if err != nil {
 			return nil, err
 		}
-		defer resp.Body.Close()
+		mirrors[i] = &mirror{url: u, fetcher: fetcher}
 
-		size = resp.ContentLength
+		res, resErr := fetcher.Resolve(context.Background(), u)
+		if resErr != nil {
+			if size <= 0 {
+				return nil, fmt.Errorf("resolve mirror %s: %w", u, resErr)
+			}
+			// size 已经确定，这个探测失败的镜像仍保留在池中；某个块在它上面
+			// 失败后，该块的重试会由 pickMirror 排除它、改派给别的源
+			continue
+		}
+
+		if size <= 0 {
+			size = res.Size
+		} else if res.Size > 0 && res.Size != size {
+			return nil, fmt.Errorf("mirror %s: size %d does not match %d", u, res.Size, size)
+		}
+
+		if fingerprint == "" {
+			fingerprint = res.Fingerprint
+		} else if res.Fingerprint != "" && res.Fingerprint != fingerprint {
+			return nil, fmt.Errorf("mirror %s: fingerprint does not match other mirrors", u)
+		}
+
+		if !res.AcceptRanges {
+			acceptRanges = false
+		}
 	}
 
 	if size <= 0 {
-		return nil, errors.New("HTTP HEAD response without \"Content-Length\"")
+		return nil, errors.New("could not determine remote resource size")
 	}
 
 	dl := &FileDownloader{
-		Url:      url,
-		Size:     size,
-		File:     file,
-		Status:   &Status{Downloaded: 0, Speeds: 0},
-		stopChan: make(chan struct{}),
+		Urls:         urls,
+		Size:         size,
+		File:         file,
+		Fingerprint:  fingerprint,
+		AcceptRanges: acceptRanges,
+		Status:       &Status{Downloaded: 0, Speeds: 0},
+		stopChan:     make(chan struct{}),
+		journalPath:  journalPath(outfile),
+		mirrors:      mirrors,
+	}
+	dl.pauseCond = sync.NewCond(&dl.pauseMu)
+
+	if resume {
+		if j, err := loadJournal(outfile); err == nil && j != nil &&
+			sameURLSet(j.Urls, urls) && j.Size == size &&
+			fingerprint != "" && j.Fingerprint == fingerprint {
+			dl.BlockList = j.Blocks
+
+			var done int64
+			for _, b := range dl.BlockList {
+				done += b.Downloaded
+			}
+			dl.Status.Downloaded = done
+		}
+	}
+
+	// 没有恢复出 BlockList（没要求续传、journal 不存在，或 journal 与本次
+	// 的 urls/size/fingerprint 对不上），说明要从头下载。file 可能是上一次
+	// 下载（甚至是另一个版本的资源）留下的，比 size 更长，必须截断，否则
+	// 新下载的 [0,Size) 之外会残留一段垃圾数据
+	if len(dl.BlockList) == 0 {
+		if err := file.Truncate(size); err != nil {
+			return nil, fmt.Errorf("truncate %s: %w", outfile, err)
+		}
 	}
 
 	return dl, nil
@@ -101,20 +185,35 @@ func NewFileDownloader(url string, file *os.File, size int64) (*FileDownloader,
 // The last-byte-pos value gives the byte-offset of the last byte in the range;
 // that is, the byte positions specified are inclusive.
 // Byte offsets start at zero.
-func (f *FileDownloader) Start() {
-	if f.Size <= 0 {
-		f.BlockList = append(f.BlockList, Block{0, -1})
-		f.Size = 1
-	} else {
-		blockSize := f.Size / int64(MaxThread)
-		// 数据平均分配给各个线程
-		for i := 0; i < MaxThread; i++ {
-			begin := blockSize * (int64(i))
-			end := begin + blockSize - 1
-			f.BlockList = append(f.BlockList, Block{begin, end})
+func (f *FileDownloader) Start(ctx context.Context) {
+	f.ctx, f.cancel = context.WithCancel(ctx)
+	f.genCtx, f.genCancel = context.WithCancel(f.ctx)
+
+	// BlockList 可能已经由 NewFileDownloader 从 resume journal 中恢复
+	if len(f.BlockList) == 0 {
+		if f.Size <= 0 || !f.AcceptRanges {
+			// 服务器不支持按范围下载（或连 HEAD 都探测不到大小），
+			// 退化为单个覆盖整个文件的块，由 fetcher 按流式写入
+			f.BlockList = append(f.BlockList, Block{0, -1, 0})
+			if f.Size <= 0 {
+				f.Size = 1
+			}
+		} else {
+			threads := f.blockCount()
+			blockSize := f.Size / int64(threads)
+			// 数据平均分配给各个线程
+			for i := 0; i < threads; i++ {
+				begin := blockSize * (int64(i))
+				end := begin + blockSize - 1
+				f.BlockList = append(f.BlockList, Block{begin, end, 0})
+			}
+			// 将余出数据分配给最后一个线程
+			f.BlockList[threads-1].End = f.Size - 1
 		}
-		// 将余出数据分配给最后一个线程
-		f.BlockList[MaxThread-1].End = f.Size - 1
+	}
+
+	if err := f.saveJournal(); err != nil {
+		f.emitErr(fmt.Errorf("write resume journal: %w", err))
 	}
 
 	f.emit(f.onStart)
@@ -122,6 +221,45 @@ func (f *FileDownloader) Start() {
 	f.download()
 }
 
+// blockCount 决定把 Size 拆成多少个并行块：ChunkSize 优先于 Threads，
+// 两者都未设置时按 defaultBlockCount 依文件大小自动选择
+func (f *FileDownloader) blockCount() int {
+	if f.ChunkSize > 0 {
+		n := int(f.Size / f.ChunkSize)
+		if n < 1 {
+			n = 1
+		}
+		return minInt(n, MaxThread)
+	}
+	if f.Threads > 0 {
+		return minInt(f.Threads, MaxThread)
+	}
+	return defaultBlockCount(f.Size)
+}
+
+// defaultBlockCount 让线程数随文件大小变化：1MB 以下单线程，
+// 100MB 以上用满 MaxThread，中间线性插值，避免小文件被拆成一堆空块
+func defaultBlockCount(size int64) int {
+	const mb = 1 << 20
+
+	switch {
+	case size < mb:
+		return 1
+	case size >= 100*mb:
+		return MaxThread
+	default:
+		n := 1 + (size-mb)*int64(MaxThread-1)/(99*mb)
+		return minInt(int(n), MaxThread)
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func (f *FileDownloader) download() {
 	go f.updateSpeeds()
 
@@ -133,104 +271,242 @@ func (f *FileDownloader) download() {
 				wg.Done()
 			}()
 
+			var failed *mirror
 			retries := 0
 			for retries < MaxRetries {
-				err := f.downloadBlock(id)
-				if err != nil {
-					retries++
-					f.emitErr(fmt.Errorf("block %d download failed (attempt %d/%d): %w", id, retries, MaxRetries, err))
-					if retries >= MaxRetries {
+				m, err := f.downloadBlock(id, failed)
+				if err == nil {
+					return
+				}
+
+				if errors.Is(err, context.Canceled) {
+					if f.ctx.Err() != nil {
+						// 整个下载被 Cancel，不是 Pause，别再重试了
 						return
 					}
-					time.Sleep(time.Second * time.Duration(retries))
+					// 只是被 Pause 打断；downloadBlock 下次会先在
+					// waitIfPaused 里挡住，不算一次失败的重试
 					continue
 				}
-				return
+
+				retries++
+				f.emitErr(fmt.Errorf("block %d download failed (attempt %d/%d): %w", id, retries, MaxRetries, err))
+				if retries >= MaxRetries {
+					atomic.AddInt32(&f.failedBlocks, 1)
+					return
+				}
+				// 换一个镜像重试，而不是反复戳同一个失败的源
+				failed = m
+				time.Sleep(time.Second * time.Duration(retries))
 			}
 		}(i)
 	}
 
 	wg.Wait()
 	close(f.stopChan)
+
+	// maybeSaveJournal 节流过的写入可能漏掉了最后一段进度，这里强制落盘一次
+	if err := f.saveJournal(); err != nil {
+		f.emitErr(fmt.Errorf("write resume journal: %w", err))
+	}
+
 	f.emit(f.onFinish)
 }
 
 // 文件块下载器
-// 根据线程ID获取下载块的起始位置
-func (f *FileDownloader) downloadBlock(id int) error {
-	begin := f.BlockList[id].Begin
-	end := f.BlockList[id].End
+// 为 id 选择一个镜像源并交给其 fetcher 完成实际的协议交互，exclude 是上次
+// 为该块失败的镜像（如果有）。返回实际使用的镜像，供调用方在重试时排除它、
+// 并记录测速结果
+func (f *FileDownloader) downloadBlock(id int, exclude *mirror) (*mirror, error) {
+	ctx := f.waitIfPaused()
+
+	f.mu.Lock()
+	block := f.BlockList[id]
+	before := block.Downloaded
+	f.mu.Unlock()
+
+	m := f.pickMirror(id, exclude)
 
-	request, err := http.NewRequest("GET", f.Url, nil)
+	start := time.Now()
+	err := m.fetcher.Fetch(ctx, m.url, block, &blockWriter{f: f, id: id})
+	elapsed := time.Since(start)
+
+	f.mu.Lock()
+	after := f.BlockList[id].Downloaded
+	f.mu.Unlock()
+
+	if delta := after - before; delta > 0 && elapsed > 0 {
+		m.recordSpeed(float64(delta) / elapsed.Seconds())
+	}
 	if err != nil {
-		return err
+		m.penalize()
 	}
 
-	if end != -1 {
-		rangeHeader := "bytes=" + strconv.FormatInt(begin, 10) + "-" + strconv.FormatInt(end, 10)
-		request.Header.Set("Range", rangeHeader)
+	return m, err
+}
+
+// blockWriter wraps FileDownloader.File so that every WriteAt a Fetcher
+// makes also advances that block's resume cursor, updates Status and
+// persists the journal -- keeping Fetchers themselves unaware of resume.
+type blockWriter struct {
+	f  *FileDownloader
+	id int
+}
+
+func (w *blockWriter) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.f.File.WriteAt(p, off)
+	if n > 0 {
+		written := int64(n)
+
+		w.f.mu.Lock()
+		w.f.BlockList[w.id].Downloaded += written
+		w.f.mu.Unlock()
+
+		w.f.Status.WithLock(func() {
+			w.f.Status.Downloaded += written
+		}, true)
+
+		w.f.maybeSaveJournal()
 	}
+	return n, err
+}
 
-	resp, err := httpClient.Do(request)
-	if err != nil {
+// maybeSaveJournal persists the journal at most once every
+// journalSaveInterval. FlushJournal (the interrupt handler) and download's
+// final save bypass this and always persist immediately.
+func (f *FileDownloader) maybeSaveJournal() {
+	f.journalMu.Lock()
+	if time.Since(f.lastJournalSave) < journalSaveInterval {
+		f.journalMu.Unlock()
+		return
+	}
+	f.lastJournalSave = time.Now()
+	f.journalMu.Unlock()
+
+	if err := f.saveJournal(); err != nil {
+		f.emitErr(fmt.Errorf("update resume journal: %w", err))
+	}
+}
+
+// saveJournal 将当前 BlockList 原子地写入 <outfile>.mget.json
+// 持锁贯穿整个写入过程，避免多个块并发写入同一个 .tmp 文件时相互踩踏
+func (f *FileDownloader) saveJournal() error {
+	if f.journalPath == "" {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	blocks := make([]Block, len(f.BlockList))
+	copy(blocks, f.BlockList)
+
+	j := &Journal{
+		Urls:        f.Urls,
+		Size:        f.Size,
+		Fingerprint: f.Fingerprint,
+		Blocks:      blocks,
+		path:        f.journalPath,
+	}
+	return j.Save()
+}
+
+// FlushJournal 立即持久化当前进度，供中断信号处理器在退出前调用
+func (f *FileDownloader) FlushJournal() error {
+	return f.saveJournal()
+}
+
+// DeleteJournal 在下载干净完成后移除 resume journal
+func (f *FileDownloader) DeleteJournal() error {
+	if f.journalPath == "" {
+		return nil
+	}
+	if err := os.Remove(f.journalPath); err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	var buf = make([]byte, CacheSize)
-	for {
-		n, e := resp.Body.Read(buf)
-
-		bufSize := int64(len(buf[:n]))
-		if end != -1 {
-			sizeNeeds := end - begin + 1
-			// 检查下载的大小是否超出需要下载的大小
-			if bufSize > sizeNeeds {
-				// 数据大小不正常
-				// 一般是因为网络环境不好导致
-				// 比如用中国电信下载国外文件
-
-				// 设置数据大小来去掉多余数据
-				// 并结束这个线程的下载
-				bufSize = sizeNeeds
-				n = int(sizeNeeds)
-				e = io.EOF
-			}
-		}
-		if bufSize > 0 {
-			// 将缓冲数据写入硬盘
-			_, writeErr := f.File.WriteAt(buf[:bufSize], begin)
-			if writeErr != nil {
-				return fmt.Errorf("write to file failed: %w", writeErr)
-			}
+// Failed 报告是否有区块在用尽重试次数后仍未下载成功
+func (f *FileDownloader) Failed() bool {
+	return atomic.LoadInt32(&f.failedBlocks) > 0
+}
 
-			// 更新已下载大小
-			f.Status.WithLock(func() {
-				f.Status.Downloaded += bufSize
-			}, true)
-			begin += bufSize
-		}
+// Canceled 报告下载是否被 Cancel 中止过（无论是否还有区块没下完）
+func (f *FileDownloader) Canceled() bool {
+	return f.ctx != nil && f.ctx.Err() != nil
+}
 
-		if e != nil {
-			if e == io.EOF {
-				// 数据已经下载完毕
-				return nil
-			}
-			return e
-		}
+// Pause 取消当前这一代下载上下文，令所有正在进行中的 Fetch 请求中止，
+// 并让每个块的下一次尝试在 waitIfPaused 里挂起，直到 Resume 被调用
+func (f *FileDownloader) Pause() {
+	f.pauseMu.Lock()
+	defer f.pauseMu.Unlock()
+
+	if f.paused {
+		return
 	}
+	f.paused = true
+	f.genCancel()
 }
 
-func (f *FileDownloader) HumanSize() string {
-	units := []string{"bytes", "KB", "MB", "GB", "PB"}
-	tmp := float64(f.Size)
-	for _, unit := range units {
-		if tmp < 1024 {
-			return fmt.Sprintf("%.3f %v", tmp, unit)
-		}
-		tmp = tmp / 1024
+// Resume 解除 Pause，为后续的块下载尝试换上一个新的上下文
+func (f *FileDownloader) Resume() {
+	f.pauseMu.Lock()
+	defer f.pauseMu.Unlock()
+
+	if !f.paused {
+		return
+	}
+	f.paused = false
+	f.genCtx, f.genCancel = context.WithCancel(f.ctx)
+	f.pauseCond.Broadcast()
+}
+
+// Paused 报告下载当前是否处于暂停状态
+func (f *FileDownloader) Paused() bool {
+	f.pauseMu.Lock()
+	defer f.pauseMu.Unlock()
+	return f.paused
+}
+
+// Cancel 彻底中止下载：取消总上下文，并唤醒任何仍在 Pause 中等待的块，
+// 让它们发现总上下文已取消后退出，而不是永远卡在暂停上
+func (f *FileDownloader) Cancel() {
+	f.cancel()
+
+	f.pauseMu.Lock()
+	defer f.pauseMu.Unlock()
+	if f.paused {
+		f.paused = false
+		f.pauseCond.Broadcast()
+	}
+}
+
+// waitIfPaused 在下载被 Pause 期间阻塞调用方，返回时给出当前这一代可用的
+// 上下文，供 Fetch 调用使用
+func (f *FileDownloader) waitIfPaused() context.Context {
+	f.pauseMu.Lock()
+	defer f.pauseMu.Unlock()
+
+	for f.paused {
+		f.pauseCond.Wait()
 	}
-	return fmt.Sprintf("%v %v", tmp, "???")
+	return f.genCtx
+}
+
+// Snapshot 返回 BlockList 的一份快照，供进度展示读取而不必持锁
+func (f *FileDownloader) Snapshot() []Block {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	blocks := make([]Block, len(f.BlockList))
+	copy(blocks, f.BlockList)
+	return blocks
+}
+
+func (f *FileDownloader) HumanSize() string {
+	return humanSize(f.Size)
 }
 
 // 任务开始时触发的事件
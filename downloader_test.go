@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestDefaultBlockCount(t *testing.T) {
+	const mb = 1 << 20
+
+	cases := []struct {
+		name string
+		size int64
+		want int
+	}{
+		{"under 1MB", mb - 1, 1},
+		{"at 1MB", mb, 1},
+		{"at 100MB", 100 * mb, MaxThread},
+		{"well above 100MB", 500 * mb, MaxThread},
+		{"midway", 50 * mb, 0}, // just check it's within bounds below
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := defaultBlockCount(c.size)
+			if got < 1 || got > MaxThread {
+				t.Fatalf("defaultBlockCount(%d) = %d, out of [1, %d]", c.size, got, MaxThread)
+			}
+			if c.want != 0 && got != c.want {
+				t.Errorf("defaultBlockCount(%d) = %d, want %d", c.size, got, c.want)
+			}
+		})
+	}
+}
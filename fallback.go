@@ -16,14 +16,14 @@ func downloadAsOne(url, out string) error {
 	}
 	defer resp.Body.Close()
 
-	contents, err := io.ReadAll(resp.Body)
+	file, err := os.Create(out)
 	if err != nil {
-		return fmt.Errorf("download: reading response body: %w", err)
+		return fmt.Errorf("download: creating file: %w", err)
 	}
+	defer file.Close()
 
-	err = os.WriteFile(out, contents, 0644)
-	if err != nil {
-		return fmt.Errorf("download: creating file: %w", err)
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return fmt.Errorf("download: writing file: %w", err)
 	}
 	return nil
 }
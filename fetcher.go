@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// Resource describes a remote object as resolved by a Fetcher, without
+// downloading its body.
+type Resource struct {
+	Size         int64
+	AcceptRanges bool
+	Filename     string // from e.g. Content-Disposition; empty if unknown
+
+	// Fingerprint identifies this exact version of the resource (an ETag or
+	// Last-Modified timestamp, say) so the resume journal can tell whether a
+	// partially downloaded file is still safe to continue. Fetchers that
+	// have no such concept may leave it empty, which simply disables resume
+	// for that scheme.
+	Fingerprint string
+}
+
+// Fetcher abstracts the protocol used to resolve and retrieve a remote
+// resource, so FileDownloader's block-splitting, speed tracking and resume
+// logic stay scheme-agnostic.
+type Fetcher interface {
+	// Resolve inspects rawURL and reports its size and capabilities without
+	// downloading the body.
+	Resolve(ctx context.Context, rawURL string) (*Resource, error)
+
+	// Fetch downloads chunk of rawURL into w, starting at chunk.Begin.
+	Fetch(ctx context.Context, rawURL string, chunk Block, w io.WriterAt) error
+}
+
+// FetcherBuilder constructs a fresh Fetcher. Fetchers register a builder for
+// the URL scheme(s) they handle from an init() function.
+type FetcherBuilder func() Fetcher
+
+var fetcherBuilders = map[string]FetcherBuilder{}
+
+// RegisterFetcher makes builder available for the given URL scheme.
+func RegisterFetcher(scheme string, builder FetcherBuilder) {
+	fetcherBuilders[scheme] = builder
+}
+
+// newFetcher picks a Fetcher for rawURL based on its scheme.
+func newFetcher(rawURL string) (Fetcher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+
+	scheme := u.Scheme
+	if scheme == "" {
+		scheme = "file"
+	}
+
+	builder, ok := fetcherBuilders[scheme]
+	if !ok {
+		return nil, fmt.Errorf("no fetcher registered for scheme %q", scheme)
+	}
+	return builder(), nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterFetcher("ftp", func() Fetcher { return &ftpFetcher{} })
+}
+
+// ftpFetcher implements Fetcher over plain FTP, using REST+RETR for
+// range-like resume. It opens a fresh control connection per call, which
+// keeps it simple at the cost of a round trip; good enough for mget's
+// block-at-a-time access pattern.
+type ftpFetcher struct{}
+
+func (f *ftpFetcher) Resolve(ctx context.Context, rawURL string) (*Resource, error) {
+	conn, u, err := dialFTP(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	if err := conn.PrintfLine("SIZE %s", u.Path); err != nil {
+		return nil, err
+	}
+	_, msg, err := conn.ReadResponse(213)
+	if err != nil {
+		return nil, fmt.Errorf("ftp: SIZE %s: %w", u.Path, err)
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ftp: malformed SIZE response: %w", err)
+	}
+
+	return &Resource{
+		Size: size,
+		// REST is near-universally available alongside SIZE, so treat range
+		// support as implied rather than probing for it separately.
+		AcceptRanges: true,
+		Filename:     path.Base(u.Path),
+	}, nil
+}
+
+func (f *ftpFetcher) Fetch(ctx context.Context, rawURL string, chunk Block, w io.WriterAt) error {
+	begin := chunk.Begin + chunk.Downloaded
+	end := chunk.End
+
+	if end != -1 && begin > end {
+		return nil
+	}
+
+	conn, u, err := dialFTP(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	data, err := ftpPassive(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	defer closeOnDone(ctx, data)()
+
+	if begin > 0 {
+		if err := conn.PrintfLine("REST %d", begin); err != nil {
+			return err
+		}
+		if _, _, err := conn.ReadResponse(350); err != nil {
+			return fmt.Errorf("ftp: REST %d: %w", begin, err)
+		}
+	}
+
+	if err := conn.PrintfLine("RETR %s", u.Path); err != nil {
+		return err
+	}
+	if _, _, err := conn.ReadResponse(150); err != nil {
+		return fmt.Errorf("ftp: RETR %s: %w", u.Path, err)
+	}
+
+	buf := make([]byte, CacheSize)
+	for {
+		if end != -1 && begin > end {
+			break
+		}
+
+		n, e := data.Read(buf)
+
+		bufSize := int64(n)
+		if end != -1 {
+			sizeNeeds := end - begin + 1
+			if bufSize > sizeNeeds {
+				bufSize = sizeNeeds
+				e = io.EOF
+			}
+		}
+		if bufSize > 0 {
+			if _, werr := w.WriteAt(buf[:bufSize], begin); werr != nil {
+				return fmt.Errorf("write to file failed: %w", werr)
+			}
+			begin += bufSize
+		}
+
+		if e != nil {
+			if e == io.EOF {
+				break
+			}
+			return e
+		}
+	}
+
+	data.Close()
+	_, _, err = conn.ReadResponse(226)
+	return err
+}
+
+// closeOnDone starts a goroutine that closes c as soon as ctx is done,
+// unblocking whatever control/data read or write is in flight on it --
+// textproto.Conn and net.Conn have no context-aware API of their own, so
+// this is what lets Pause/Cancel actually interrupt a stalled FTP
+// transfer. Callers must defer the returned stop func so the goroutine
+// exits once the operation finishes normally.
+func closeOnDone(ctx context.Context, c io.Closer) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// dialFTP opens a control connection to rawURL's host and logs in, using
+// anonymous credentials unless the URL carries userinfo.
+func dialFTP(ctx context.Context, rawURL string) (*textproto.Conn, *url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "21")
+	}
+
+	var d net.Dialer
+	netConn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := textproto.NewConn(netConn)
+	if _, _, err := conn.ReadResponse(220); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	user, pass := "anonymous", "anonymous"
+	if u.User != nil {
+		user = u.User.Username()
+		if p, ok := u.User.Password(); ok {
+			pass = p
+		}
+	}
+
+	if err := conn.PrintfLine("USER %s", user); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if _, _, err := conn.ReadResponse(331); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := conn.PrintfLine("PASS %s", pass); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if _, _, err := conn.ReadResponse(230); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if err := conn.PrintfLine("TYPE I"); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if _, _, err := conn.ReadResponse(200); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, u, nil
+}
+
+// ftpPassive issues PASV and dials the resulting data connection.
+func ftpPassive(ctx context.Context, conn *textproto.Conn) (net.Conn, error) {
+	if err := conn.PrintfLine("PASV"); err != nil {
+		return nil, err
+	}
+	_, msg, err := conn.ReadResponse(227)
+	if err != nil {
+		return nil, fmt.Errorf("ftp: PASV: %w", err)
+	}
+
+	addr, err := parsePASV(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// parsePASV extracts the "h1,h2,h3,h4,p1,p2" tuple from a PASV response like
+// `227 Entering Passive Mode (127,0,0,1,200,13)`.
+func parsePASV(msg string) (string, error) {
+	open := strings.Index(msg, "(")
+	closeIdx := strings.Index(msg, ")")
+	if open == -1 || closeIdx == -1 || closeIdx < open {
+		return "", fmt.Errorf("ftp: malformed PASV response: %q", msg)
+	}
+
+	parts := strings.Split(msg[open+1:closeIdx], ",")
+	if len(parts) != 6 {
+		return "", fmt.Errorf("ftp: malformed PASV response: %q", msg)
+	}
+
+	p1, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return "", fmt.Errorf("ftp: malformed PASV response: %q", msg)
+	}
+	p2, err := strconv.Atoi(parts[5])
+	if err != nil {
+		return "", fmt.Errorf("ftp: malformed PASV response: %q", msg)
+	}
+
+	host := strings.Join(parts[:4], ".")
+	port := p1*256 + p2
+	return fmt.Sprintf("%s:%d", host, port), nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestParsePASV(t *testing.T) {
+	cases := []struct {
+		name    string
+		msg     string
+		want    string
+		wantErr bool
+	}{
+		{"typical", "227 Entering Passive Mode (127,0,0,1,200,13)", "127.0.0.1:51213", false},
+		{"no parens", "227 Entering Passive Mode", "", true},
+		{"wrong field count", "227 Entering Passive Mode (127,0,0,1,200)", "", true},
+		{"non-numeric port field", "227 Entering Passive Mode (127,0,0,1,x,13)", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parsePASV(c.msg)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parsePASV(%q) = %q, nil; want error", c.msg, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePASV(%q) error: %v", c.msg, err)
+			}
+			if got != c.want {
+				t.Errorf("parsePASV(%q) = %q, want %q", c.msg, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConnsPerHost: 30,
+	},
+	Timeout: HTTPTimeout * time.Second,
+}
+
+func init() {
+	RegisterFetcher("http", func() Fetcher { return &httpFetcher{} })
+	RegisterFetcher("https", func() Fetcher { return &httpFetcher{} })
+}
+
+// httpFetcher implements Fetcher over HTTP(S) using byte-range requests.
+type httpFetcher struct{}
+
+func (f *httpFetcher) Resolve(ctx context.Context, rawURL string) (*Resource, error) {
+	headReq, err := http.NewRequestWithContext(ctx, "HEAD", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		size        int64
+		filename    string
+		fingerprint string
+	)
+
+	headResp, headErr := httpClient.Do(headReq)
+	if headErr == nil {
+		size = headResp.ContentLength
+		fingerprint = fingerprintFromHeader(headResp.Header)
+		if cd := headResp.Header.Get("Content-Disposition"); cd != "" {
+			if _, params, err := mime.ParseMediaType(cd); err == nil {
+				filename = params["filename"]
+			}
+		}
+		headResp.Body.Close()
+	}
+
+	// A HEAD's Accept-Ranges header can't be trusted: some servers
+	// advertise it but still answer a ranged GET with a full 200 body,
+	// which would silently corrupt a multi-range download. Probe with an
+	// actual single-byte range request and only believe a real 206 +
+	// Content-Range.
+	probeReq, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	probeReq.Header.Set("Range", "bytes=0-0")
+
+	var acceptRanges bool
+
+	probeResp, probeErr := httpClient.Do(probeReq)
+	if probeErr != nil {
+		if headErr != nil {
+			return nil, probeErr
+		}
+	} else {
+		io.Copy(io.Discard, probeResp.Body)
+		probeResp.Body.Close()
+
+		if fingerprint == "" {
+			fingerprint = fingerprintFromHeader(probeResp.Header)
+		}
+
+		if probeResp.StatusCode == http.StatusPartialContent {
+			if total, ok := parseContentRangeTotal(probeResp.Header.Get("Content-Range")); ok {
+				acceptRanges = true
+				if size <= 0 {
+					size = total
+				}
+			}
+		} else if size <= 0 {
+			size = probeResp.ContentLength
+		}
+	}
+
+	if size <= 0 {
+		return nil, errors.New("could not determine remote resource size")
+	}
+
+	return &Resource{
+		Size:         size,
+		AcceptRanges: acceptRanges,
+		Filename:     filename,
+		Fingerprint:  fingerprint,
+	}, nil
+}
+
+// parseContentRangeTotal extracts the total size from a Content-Range
+// header like "bytes 0-0/12345"; returns ok=false for an unknown total
+// ("bytes 0-0/*") or a malformed header.
+func parseContentRangeTotal(headerVal string) (int64, bool) {
+	idx := strings.LastIndex(headerVal, "/")
+	if idx == -1 || idx == len(headerVal)-1 {
+		return 0, false
+	}
+
+	totalStr := headerVal[idx+1:]
+	if totalStr == "*" {
+		return 0, false
+	}
+
+	total, err := strconv.ParseInt(totalStr, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return total, true
+}
+
+// 根据 chunk 的起止位置获取数据
+// see https://tools.ietf.org/html/rfc7233#section-2.1
+// The last-byte-pos value gives the byte-offset of the last byte in the range;
+// that is, the byte positions specified are inclusive.
+// Byte offsets start at zero.
+func (f *httpFetcher) Fetch(ctx context.Context, rawURL string, chunk Block, w io.WriterAt) error {
+	begin := chunk.Begin + chunk.Downloaded
+	end := chunk.End
+
+	if end != -1 && begin > end {
+		// 该块在上一次运行中已经下载完成
+		return nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case end != -1:
+		request.Header.Set("Range", "bytes="+strconv.FormatInt(begin, 10)+"-"+strconv.FormatInt(end, 10))
+	case begin > 0:
+		// 单流回退块（不支持 range 的镜像）在续传时同样要带上 Range，
+		// 否则服务器会从头重发整个 body，而我们却从 begin 处开始写入，
+		// 导致内容错位、文件变大
+		request.Header.Set("Range", "bytes="+strconv.FormatInt(begin, 10)+"-")
+	}
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var buf = make([]byte, CacheSize)
+	for {
+		n, e := resp.Body.Read(buf)
+
+		bufSize := int64(len(buf[:n]))
+		if end != -1 {
+			sizeNeeds := end - begin + 1
+			// 检查下载的大小是否超出需要下载的大小
+			if bufSize > sizeNeeds {
+				// 数据大小不正常
+				// 一般是因为网络环境不好导致
+				// 比如用中国电信下载国外文件
+
+				// 设置数据大小来去掉多余数据
+				// 并结束这个线程的下载
+				bufSize = sizeNeeds
+				n = int(sizeNeeds)
+				e = io.EOF
+			}
+		}
+		if bufSize > 0 {
+			// 将缓冲数据写入硬盘
+			if _, writeErr := w.WriteAt(buf[:bufSize], begin); writeErr != nil {
+				return fmt.Errorf("write to file failed: %w", writeErr)
+			}
+			begin += bufSize
+		}
+
+		if e != nil {
+			if e == io.EOF {
+				// 数据已经下载完毕
+				return nil
+			}
+			return e
+		}
+	}
+}
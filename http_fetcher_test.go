@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestParseContentRangeTotal(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		want   int64
+		wantOk bool
+	}{
+		{"known total", "bytes 0-0/12345", 12345, true},
+		{"unknown total", "bytes 0-0/*", 0, false},
+		{"no slash", "bytes 0-0", 0, false},
+		{"empty", "", 0, false},
+		{"non-numeric total", "bytes 0-0/abc", 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := parseContentRangeTotal(c.header)
+			if ok != c.wantOk {
+				t.Fatalf("parseContentRangeTotal(%q) ok = %v, want %v", c.header, ok, c.wantOk)
+			}
+			if ok && got != c.want {
+				t.Errorf("parseContentRangeTotal(%q) = %d, want %d", c.header, got, c.want)
+			}
+		})
+	}
+}
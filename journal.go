@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+)
+
+// Journal is the on-disk sidecar (<outfile>.mget.json) that lets an
+// interrupted download resume instead of restarting. It mirrors the
+// FileDownloader's BlockList plus enough identity info (Urls, Size and a
+// Fingerprint from the initial HEAD) to tell whether a partial file still
+// matches the remote resource.
+type Journal struct {
+	Urls        []string
+	Size        int64
+	Fingerprint string
+	Blocks      []Block
+
+	path string
+}
+
+// sameURLSet reports whether a and b contain the same URLs, ignoring order
+// -- a resumed download may list its mirrors in a different order than the
+// run that wrote the journal.
+func sameURLSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, u := range a {
+		counts[u]++
+	}
+	for _, u := range b {
+		counts[u]--
+		if counts[u] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// journalPath returns the sidecar path for a given output file.
+func journalPath(outfile string) string {
+	return outfile + ".mget.json"
+}
+
+// loadJournal reads the sidecar for outfile. It returns a nil Journal
+// (without error) when no sidecar exists.
+func loadJournal(outfile string) (*Journal, error) {
+	path := journalPath(outfile)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	j := &Journal{path: path}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Save atomically rewrites the sidecar file via a temp file + rename, so a
+// crash mid-write never leaves a truncated journal behind.
+func (j *Journal) Save() error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// fingerprintFromHeader derives a fingerprint for change detection from an
+// HTTP response's ETag or, failing that, its Last-Modified header.
+func fingerprintFromHeader(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestSameURLSet(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b []string
+		want bool
+	}{
+		{"identical order", []string{"a", "b"}, []string{"a", "b"}, true},
+		{"different order", []string{"a", "b"}, []string{"b", "a"}, true},
+		{"different length", []string{"a", "b"}, []string{"a"}, false},
+		{"different contents", []string{"a", "b"}, []string{"a", "c"}, false},
+		{"duplicate in one", []string{"a", "a"}, []string{"a", "b"}, false},
+		{"both empty", nil, nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := sameURLSet(c.a, c.b); got != c.want {
+				t.Errorf("sameURLSet(%v, %v) = %v, want %v", c.a, c.b, got, c.want)
+			}
+		})
+	}
+}
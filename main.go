@@ -1,48 +1,127 @@
 package main
 
-// interrupt by ^C -> download function -> stop downloading -> release file -> check and delete file
-// download success -> close file -> check and delete file
+// interrupt by ^C -> flush the resume journal -> release file, keeping the
+// partial file and journal on disk so a later -c/--continue can resume it
+// download success -> delete the resume journal -> verify checksum (requested
+// via flags, or advertised by the server)
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	fp "path/filepath"
+	"strings"
 	"time"
 )
 
+// errCanceled is returned by multiRangeDownload when the user quit the
+// download (pressing 'q') rather than it failing outright; downloadIt
+// treats the two differently, since a cancel shouldn't trigger the
+// single-stream fallback.
+var errCanceled = errors.New("download canceled")
+
 var (
-	outfile    string
-	multiParts = true
+	outfile          string
+	multiParts       = true
+	continueDownload bool
+	threads          int
+	chunkSize        int64
+
+	sha256Sum    string
+	md5Sum       string
+	checksumFile string
+
+	// flushOnInterrupt, when set, persists the resume journal of the
+	// in-flight download; captureInterrupt calls it before exiting.
+	flushOnInterrupt func() error
 )
 
 func init() {
 	flag.StringVar(&outfile, "o", outfile, "Output file path.")
 	flag.BoolVar(&multiParts, "m", multiParts, "Download the file by multiple parts")
+	flag.BoolVar(&continueDownload, "c", continueDownload, "Resume an interrupted download from its .mget.json sidecar")
+	flag.BoolVar(&continueDownload, "continue", continueDownload, "Alias for -c")
+	flag.IntVar(&threads, "threads", 0, "Number of parallel range requests (0 = auto, scaled by file size)")
+	flag.Int64Var(&chunkSize, "chunk-size", 0, "Target bytes per thread; overrides -threads when set (0 = auto)")
+	flag.StringVar(&sha256Sum, "sha256", "", "Expected SHA-256 checksum (hex); verified after download")
+	flag.StringVar(&md5Sum, "md5", "", "Expected MD5 checksum (hex); verified after download")
+	flag.StringVar(&checksumFile, "checksum-file", "", "URL of a sha256sum/md5sum-style checksum file listing the expected digest for the source URL's filename")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s URL\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s URL [mirrorURL...]\n\n", os.Args[0])
 		flag.PrintDefaults()
 	}
-	flag.Parse()
+}
+
+// parseFlags parses args and returns the positional URL arguments. Unlike a
+// bare flag.Parse(), flags may appear anywhere among args -- including
+// after the URL list, e.g. "mget URL1 URL2 -o out" -- since flag.Parse on
+// its own stops scanning at the first non-flag token and would otherwise
+// swallow a later flag (and its value) as a bogus extra mirror URL.
+func parseFlags(args []string) []string {
+	flagArgs, urls := splitFlagsAndPositionals(args)
+	flag.CommandLine.Parse(flagArgs)
+	return append(urls, flag.Args()...)
+}
+
+// splitFlagsAndPositionals walks args and separates recognized flag tokens
+// (together with their value, when the flag takes one) from everything
+// else, which is assumed to be a URL. A bare "--" ends flag scanning; all
+// remaining args are treated as positionals.
+func splitFlagsAndPositionals(args []string) (flagArgs, positionals []string) {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			positionals = append(positionals, args[i+1:]...)
+			break
+		}
+		if len(a) < 2 || a[0] != '-' {
+			positionals = append(positionals, a)
+			continue
+		}
+
+		flagArgs = append(flagArgs, a)
+
+		name := strings.TrimLeft(a, "-")
+		if strings.ContainsRune(name, '=') {
+			// -flag=value already carries its value in this token
+			continue
+		}
+
+		fl := flag.Lookup(name)
+		if fl == nil {
+			// Unknown flag; let flag.Parse report the error as usual.
+			continue
+		}
+		if bf, ok := fl.Value.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() {
+			continue
+		}
+		if i+1 < len(args) {
+			i++
+			flagArgs = append(flagArgs, args[i])
+		}
+	}
+	return flagArgs, positionals
 }
 
 func main() {
-	url := flag.Arg(0)
-	if url == "" {
+	urls := parseFlags(os.Args[1:])
+	if len(urls) == 0 {
 		fmt.Fprintf(os.Stderr, "Please give the URL!\n")
 		os.Exit(1)
 	}
 
 	if outfile == "" {
-		outfile = fp.Base(url)
+		outfile = fp.Base(urls[0])
 	}
 
-	start(url, outfile)
+	start(urls, outfile)
 }
 
-func start(url, outfile string) {
+func start(urls []string, outfile string) {
 	defer func(start time.Time) {
 		elapsed := time.Since(start)
 		log.Printf("Time took %s", elapsed)
@@ -50,44 +129,117 @@ func start(url, outfile string) {
 	PrepareDir(outfile)
 
 	done := make(chan bool)
-	go captureInterrupt(done)
+	go captureInterrupt(func() {
+		if flushOnInterrupt == nil {
+			return
+		}
+		if err := flushOnInterrupt(); err != nil {
+			log.Println("flush resume journal:", err)
+		}
+	})
 
 	go func() {
-		downloadIt(url, outfile)
+		downloadIt(urls, outfile)
 		done <- true
 	}()
 
 	<-done
 }
 
-func downloadIt(url, outfile string) error {
-	if _, err := os.Stat(outfile); err == nil {
+func downloadIt(urls []string, outfile string) error {
+	if _, err := os.Stat(outfile); err == nil && !continueDownload {
 		log.Println("file already exists:", outfile)
 		return nil
 	}
 
 	var err error
 	if multiParts {
-		if err = multiRangeDownload(url, outfile); err != nil {
+		if err = multiRangeDownload(urls, outfile); err != nil {
+			if errors.Is(err, errCanceled) {
+				return err
+			}
 			log.Println(err)
-			err = downloadAsOne(url, outfile)
+			if continueDownload {
+				log.Println("warning: falling back to a single-stream download, which does not support -c/--continue; re-downloading from scratch")
+			}
+			err = downloadAsOne(urls[0], outfile)
 		}
 	} else {
-		err = downloadAsOne(url, outfile)
+		if continueDownload {
+			log.Println("warning: -c/--continue has no effect with -m=false; single-stream downloads always start from scratch")
+		}
+		err = downloadAsOne(urls[0], outfile)
 	}
 
 	if err != nil {
 		return err
 	}
 
-	log.Printf("%v => %v\n", url, outfile)
+	if err := verifyDownload(urls[0], outfile); err != nil {
+		deleteFile(outfile)
+		return err
+	}
+
+	log.Printf("%v => %v\n", urls[0], outfile)
 	return nil
 }
 
-func multiRangeDownload(url, out string) (err error) {
+// verifyDownload checks outfile's integrity after a successful download.
+// An explicit -sha256/-md5/-checksum-file flag takes priority; otherwise it
+// falls back to whatever digest the server advertised for url. It is a
+// no-op (nil error) when nothing to verify against is available.
+func verifyDownload(url, outfile string) error {
+	algo, want := "", ""
+	switch {
+	case sha256Sum != "":
+		algo, want = "sha256", sha256Sum
+	case md5Sum != "":
+		algo, want = "md5", md5Sum
+	case checksumFile != "":
+		digest, err := checksumFromFile(checksumFile, fp.Base(url))
+		if err != nil {
+			return err
+		}
+		algo, want = guessAlgoFromHex(digest), digest
+	default:
+		resp, err := httpClient.Head(url)
+		if err != nil {
+			// No way to verify; that's fine, just skip it.
+			return nil
+		}
+		resp.Body.Close()
+
+		checksums := checksumsFromHeader(resp.Header)
+		switch {
+		case checksums.MD5 != "":
+			algo, want = "md5", checksums.MD5
+		case checksums.CRC64ECMA != "":
+			algo, want = "crc64ecma", checksums.CRC64ECMA
+		}
+	}
+
+	if algo == "" || want == "" {
+		return nil
+	}
+
+	got, err := verifyFile(outfile, algo, want)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%s: %s OK (%s)\n", outfile, algo, got)
+	return nil
+}
+
+func multiRangeDownload(urls []string, out string) (err error) {
 	log.Println("download using ranges...")
 
-	outfile, err := os.Create(out)
+	var outfile *os.File
+	if continueDownload {
+		outfile, err = os.OpenFile(out, os.O_RDWR|os.O_CREATE, 0644)
+	} else {
+		outfile, err = os.Create(out)
+	}
 	if err != nil {
 		return err
 	}
@@ -101,52 +253,49 @@ func multiRangeDownload(url, out string) (err error) {
 		}
 	}()
 
-	dl, err := NewFileDownloader(url, outfile, -1)
+	dl, err := NewFileDownloader(urls, outfile, -1, out, continueDownload)
 	if err != nil {
 		return err
 	}
+	dl.Threads = threads
+	dl.ChunkSize = chunkSize
 
-	// finish downloading or canceled by user, print result
-	finishChan := make(chan bool)
-	dl.OnFinish(func() {
-		finishChan <- true
+	flushOnInterrupt = dl.FlushJournal
+	defer func() { flushOnInterrupt = nil }()
+
+	dl.OnStart(func() {
+		log.Printf("start download %v\n", out)
+		log.Printf("total size: %v\n", dl.HumanSize())
+		if len(urls) > 1 {
+			log.Printf("using %d mirrors\n", len(urls))
+		}
 	})
 
 	dl.OnError(func(err error) {
 		log.Println(err)
 	})
 
-	done := make(chan bool)
-	dl.OnStart(func() {
-		log.Printf("start download %v\n", out)
-		log.Printf("total size: %v\n", dl.HumanSize())
-		format := "\r %9d KB/s %v"
-
-		status := dl.Status
-		var lastSpeed int64
-		ticker := time.NewTicker(time.Millisecond * 500)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-finishChan:
-				status.WithLock(func() {
-					fmt.Printf(format, lastSpeed, "[ FINISHED! ]")
-					os.Stdout.Sync()
-				}, false)
-				done <- true
-				return
-			case <-ticker.C:
-				status.WithLock(func() {
-					lastSpeed = status.Speeds / 1024
-					fmt.Printf(format, lastSpeed, "[DOWNLOADING]")
-					os.Stdout.Sync()
-				}, false)
-			}
-		}
+	finishChan := make(chan struct{})
+	dl.OnFinish(func() {
+		close(finishChan)
 	})
 
-	go dl.Start()
-	<-done
+	go runProgress(dl, finishChan)
+
+	go dl.Start(context.Background())
+	<-finishChan
+
+	if dl.Canceled() {
+		log.Println("download canceled, progress saved to", dl.journalPath)
+		return errCanceled
+	}
+
+	if dl.Failed() {
+		return errors.New("one or more blocks failed to download")
+	}
+
+	if err := dl.DeleteJournal(); err != nil {
+		log.Println("delete resume journal:", err)
+	}
 	return
 }
@@ -0,0 +1,84 @@
+package main
+
+import "sync"
+
+// speedEWMAAlpha weighs a fresh throughput sample against a mirror's
+// running average: higher reacts faster to a mirror speeding up or
+// slowing down, lower rides out noise from a single small block.
+const speedEWMAAlpha = 0.3
+
+// mirror is one of several sources FileDownloader may fetch a block from.
+// speed is an exponentially-weighted moving average of observed
+// bytes/sec, used to bias block assignment toward whichever mirror is
+// currently fastest.
+type mirror struct {
+	url     string
+	fetcher Fetcher
+
+	mu    sync.Mutex
+	speed float64
+}
+
+// recordSpeed folds a fresh bytes/sec sample into the mirror's EWMA.
+func (m *mirror) recordSpeed(bytesPerSec float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.speed == 0 {
+		m.speed = bytesPerSec
+		return
+	}
+	m.speed = speedEWMAAlpha*bytesPerSec + (1-speedEWMAAlpha)*m.speed
+}
+
+// penalize halves a mirror's score after a failed attempt, so a mirror
+// that starts erroring quickly loses out to its peers without being
+// excluded outright.
+func (m *mirror) penalize() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.speed *= 0.5
+}
+
+func (m *mirror) currentSpeed() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.speed
+}
+
+// pickMirror chooses which mirror should serve block id's next attempt.
+// exclude, when non-nil, is the mirror that just failed this block and
+// must not be retried immediately. Until every mirror has at least one
+// speed sample, selection round-robins by block id so all of them get a
+// chance to be measured; afterwards it picks whichever eligible mirror
+// has the highest EWMA speed.
+func (f *FileDownloader) pickMirror(id int, exclude *mirror) *mirror {
+	eligible := make([]*mirror, 0, len(f.mirrors))
+	for _, m := range f.mirrors {
+		if m != exclude {
+			eligible = append(eligible, m)
+		}
+	}
+	if len(eligible) == 0 {
+		// 所有镜像都被排除（只有一个源且它刚失败），没得选，只能重试它
+		return exclude
+	}
+	if len(eligible) == 1 {
+		return eligible[0]
+	}
+
+	allMeasured := true
+	best := eligible[0]
+	for _, m := range eligible {
+		if m.currentSpeed() == 0 {
+			allMeasured = false
+		}
+		if m.currentSpeed() > best.currentSpeed() {
+			best = m
+		}
+	}
+	if !allMeasured {
+		return eligible[id%len(eligible)]
+	}
+	return best
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestPickMirrorRoundRobinsUntilMeasured(t *testing.T) {
+	a := &mirror{url: "a"}
+	b := &mirror{url: "b"}
+	f := &FileDownloader{mirrors: []*mirror{a, b}}
+
+	// Neither mirror has a speed sample yet: block id picks by id%len.
+	if got := f.pickMirror(0, nil); got != a {
+		t.Errorf("pickMirror(0, nil) = %v, want a", got.url)
+	}
+	if got := f.pickMirror(1, nil); got != b {
+		t.Errorf("pickMirror(1, nil) = %v, want b", got.url)
+	}
+}
+
+func TestPickMirrorPrefersFastestOnceMeasured(t *testing.T) {
+	a := &mirror{url: "a"}
+	b := &mirror{url: "b"}
+	a.recordSpeed(100)
+	b.recordSpeed(200)
+	f := &FileDownloader{mirrors: []*mirror{a, b}}
+
+	if got := f.pickMirror(0, nil); got != b {
+		t.Errorf("pickMirror = %v, want fastest mirror b", got.url)
+	}
+}
+
+func TestPickMirrorExcludesFailedMirror(t *testing.T) {
+	a := &mirror{url: "a"}
+	b := &mirror{url: "b"}
+	a.recordSpeed(200)
+	b.recordSpeed(100)
+	f := &FileDownloader{mirrors: []*mirror{a, b}}
+
+	// a is the fastest, but it just failed this block -- must not be
+	// retried immediately.
+	if got := f.pickMirror(0, a); got != b {
+		t.Errorf("pickMirror(0, a) = %v, want b", got.url)
+	}
+}
+
+func TestPickMirrorReturnsExcludedWhenNoAlternative(t *testing.T) {
+	a := &mirror{url: "a"}
+	f := &FileDownloader{mirrors: []*mirror{a}}
+
+	if got := f.pickMirror(0, a); got != a {
+		t.Errorf("pickMirror(0, a) = %v, want a (no alternative)", got.url)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	overallBarWidth = 30
+	blockBarWidth   = 20
+)
+
+// runProgress redraws dl's overall and per-block progress in place until
+// done is closed, then leaves the cursor after the final frame. It also
+// watches stdin for 'p' (toggle pause) and 'q' (cancel + quit) while a
+// terminal is attached.
+func runProgress(dl *FileDownloader, done <-chan struct{}) {
+	rawMode := enableRawKeys()
+	if rawMode {
+		defer restoreKeys()
+		go watchKeys(dl)
+	}
+
+	ticker := time.NewTicker(time.Millisecond * 500)
+	defer ticker.Stop()
+
+	lines := 0
+	for {
+		select {
+		case <-done:
+			lines = renderProgress(dl, lines)
+			fmt.Println()
+			return
+		case <-ticker.C:
+			lines = renderProgress(dl, lines)
+		}
+	}
+}
+
+// renderProgress draws one frame: an overall bar/summary line followed by
+// one sub-bar per block. prevLines is how many lines the previous frame
+// used, so the cursor can be walked back up before overwriting them; it
+// returns how many lines this frame used.
+func renderProgress(f *FileDownloader, prevLines int) int {
+	blocks := f.Snapshot()
+
+	var out strings.Builder
+	if prevLines > 0 {
+		fmt.Fprintf(&out, "\x1b[%dA", prevLines)
+	}
+
+	var downloaded, speed int64
+	f.Status.WithLock(func() {
+		downloaded = f.Status.Downloaded
+		speed = f.Status.Speeds
+	}, false)
+
+	fmt.Fprintf(&out, "\x1b[2K\r%s %s\n",
+		progressBar(downloaded, f.Size, overallBarWidth),
+		overallSummary(downloaded, f.Size, speed, f.Paused()))
+
+	for i, b := range blocks {
+		size := b.End - b.Begin + 1
+		if b.End < 0 {
+			size = f.Size - b.Begin
+		}
+		fmt.Fprintf(&out, "\x1b[2K\r  block %-3d %s %s/%s\n",
+			i, progressBar(b.Downloaded, size, blockBarWidth), humanSize(b.Downloaded), humanSize(size))
+	}
+
+	fmt.Fprint(os.Stdout, out.String())
+	os.Stdout.Sync()
+
+	return 1 + len(blocks)
+}
+
+func progressBar(done, total int64, width int) string {
+	if total <= 0 {
+		total = 1
+	}
+	frac := float64(done) / float64(total)
+	if frac > 1 {
+		frac = 1
+	} else if frac < 0 {
+		frac = 0
+	}
+	filled := int(frac * float64(width))
+	return "[" + strings.Repeat("=", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+func overallSummary(downloaded, total, speed int64, paused bool) string {
+	var pct float64
+	if total > 0 {
+		pct = float64(downloaded) / float64(total) * 100
+	}
+
+	eta := "?"
+	if speed > 0 && total > downloaded {
+		eta = (time.Duration((total-downloaded)/speed) * time.Second).String()
+	}
+
+	state := "[DOWNLOADING]"
+	if paused {
+		state = "[PAUSED]"
+	}
+
+	return fmt.Sprintf("%5.1f%%  %s/%s  %d KB/s  ETA %-8s %s  (p)ause (q)uit",
+		pct, humanSize(downloaded), humanSize(total), speed/1024, eta, state)
+}
+
+// enableRawKeys puts the controlling terminal into cbreak/no-echo mode via
+// stty, so watchKeys can read single keystrokes without waiting for Enter.
+// It reports false (and does nothing) when stdin isn't a terminal, e.g.
+// when mget's output is piped or redirected.
+func enableRawKeys() bool {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	cmd := exec.Command("stty", "-F", "/dev/tty", "cbreak", "-echo")
+	cmd.Stdin = os.Stdin
+	return cmd.Run() == nil
+}
+
+// restoreKeys undoes enableRawKeys.
+func restoreKeys() {
+	cmd := exec.Command("stty", "-F", "/dev/tty", "sane")
+	cmd.Stdin = os.Stdin
+	cmd.Run()
+}
+
+// watchKeys reads single bytes from stdin, toggling dl's pause state on
+// 'p' and cancelling the download on 'q'. It returns once stdin is closed
+// or a quit is issued.
+func watchKeys(dl *FileDownloader) {
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil || n == 0 {
+			return
+		}
+
+		switch buf[0] {
+		case 'p', 'P':
+			if dl.Paused() {
+				dl.Resume()
+			} else {
+				dl.Pause()
+			}
+		case 'q', 'Q':
+			dl.Cancel()
+			return
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProgressBar(t *testing.T) {
+	cases := []struct {
+		name        string
+		done, total int64
+		width       int
+		wantFilled  int
+	}{
+		{"empty", 0, 100, 10, 0},
+		{"full", 100, 100, 10, 10},
+		{"half", 50, 100, 10, 5},
+		{"total zero clamps to full scale", 10, 0, 10, 10},
+		{"negative done clamps to zero", -5, 100, 10, 0},
+		{"done past total clamps to full", 150, 100, 10, 10},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := progressBar(c.done, c.total, c.width)
+
+			if !strings.HasPrefix(got, "[") || !strings.HasSuffix(got, "]") {
+				t.Fatalf("progressBar(%d, %d, %d) = %q, want [...] brackets", c.done, c.total, c.width, got)
+			}
+			body := got[1 : len(got)-1]
+			if len(body) != c.width {
+				t.Fatalf("progressBar(%d, %d, %d) body length = %d, want %d", c.done, c.total, c.width, len(body), c.width)
+			}
+			if filled := strings.Count(body, "="); filled != c.wantFilled {
+				t.Errorf("progressBar(%d, %d, %d) filled = %d, want %d", c.done, c.total, c.width, filled, c.wantFilled)
+			}
+		})
+	}
+}
+
+func TestOverallSummary(t *testing.T) {
+	s := overallSummary(50, 100, 10, false)
+	if !strings.Contains(s, "50.0%") {
+		t.Errorf("overallSummary: %q does not contain percent 50.0%%", s)
+	}
+	if !strings.Contains(s, "5s") {
+		t.Errorf("overallSummary: %q does not contain the expected 5s ETA", s)
+	}
+	if !strings.Contains(s, "[DOWNLOADING]") {
+		t.Errorf("overallSummary: %q missing [DOWNLOADING] state", s)
+	}
+
+	if s := overallSummary(50, 100, 10, true); !strings.Contains(s, "[PAUSED]") {
+		t.Errorf("overallSummary(paused=true): %q missing [PAUSED] state", s)
+	}
+
+	if s := overallSummary(0, 100, 0, false); !strings.Contains(s, "ETA ?") {
+		t.Errorf("overallSummary with zero speed: %q should show an unknown ETA", s)
+	}
+
+	if s := overallSummary(0, 0, 0, false); !strings.Contains(s, "0.0%") {
+		t.Errorf("overallSummary with zero total: %q should report 0%% rather than divide by zero", s)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -8,6 +9,19 @@ import (
 	"strings"
 )
 
+// humanSize formats n bytes using the largest unit that keeps it under 1024.
+func humanSize(n int64) string {
+	units := []string{"bytes", "KB", "MB", "GB", "TB", "PB"}
+	tmp := float64(n)
+	for _, unit := range units {
+		if tmp < 1024 {
+			return fmt.Sprintf("%.3f %v", tmp, unit)
+		}
+		tmp = tmp / 1024
+	}
+	return fmt.Sprintf("%v %v", tmp, "???")
+}
+
 func deleteFile(path string) {
 	err := os.Remove(path)
 	if err != nil {
@@ -28,9 +42,14 @@ func PrepareDir(filePath string) {
 	}
 }
 
-func captureInterrupt() {
+// captureInterrupt blocks until SIGINT, runs onInterrupt (e.g. to flush a
+// resume journal) and then exits.
+func captureInterrupt(onInterrupt func()) {
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt)
 	<-interrupt
+	if onInterrupt != nil {
+		onInterrupt()
+	}
 	log.Fatal("Interrupt")
 }